@@ -0,0 +1,14 @@
+package agent
+
+import "io"
+
+// WithStreamingWriter is WithWriter's counterpart for routes that opted into
+// streaming. Today it's a thin alias -- the agent treats w the same either
+// way, since it's the caller's responsibility to pass a writer (like
+// streamingResponseWriter) that already flushes incrementally. It's kept as
+// its own function, rather than routes just calling WithWriter directly, so
+// call sites read "this is a streaming call" without having to know that
+// detail lives entirely in the writer's concrete type.
+func WithStreamingWriter(w io.Writer) CallOpt {
+	return WithWriter(w)
+}
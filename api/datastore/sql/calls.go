@@ -0,0 +1,53 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/fnproject/fn/api/models"
+)
+
+// GetCalls lists calls for filter.AppID, newest first, keyset-paginated on
+// (created_at, id) rather than OFFSET/LIMIT so a concurrent insert can't
+// shift rows out from under a page and duplicate or skip calls across
+// requests. See models.CallFilter for why CreatedBefore/IDBefore have to be
+// applied as the single OR'd tuple predicate below rather than two ANDed
+// columns.
+func (ds *store) GetCalls(ctx context.Context, filter *models.CallFilter) ([]*models.Call, error) {
+	query := `SELECT id, app_id, path, type, payload, created_at
+		FROM calls
+		WHERE app_id = ?`
+	args := []interface{}{filter.AppID}
+
+	if !filter.CreatedBefore.IsZero() {
+		query += ` AND (created_at < ? OR (created_at = ? AND id < ?))`
+		args = append(args, filter.CreatedBefore, filter.CreatedBefore, filter.IDBefore)
+	}
+
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+	args = append(args, filter.PerPage)
+
+	rows, err := ds.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var calls []*models.Call
+	for rows.Next() {
+		var call models.Call
+		var createdAt sql.NullTime
+		if err := rows.Scan(&call.ID, &call.AppID, &call.Path, &call.Type, &call.Payload, &createdAt); err != nil {
+			return nil, err
+		}
+		if createdAt.Valid {
+			call.CreatedAt = models.DateTime(createdAt.Time)
+		}
+		calls = append(calls, &call)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return calls, nil
+}
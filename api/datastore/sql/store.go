@@ -0,0 +1,11 @@
+package sql
+
+import "database/sql"
+
+// store is the sql-backed models.Datastore implementation. Only the pieces
+// calls.go needs are declared here -- the rest of store's methods (apps,
+// routes, migrations, ...) live in the rest of this package, which isn't
+// part of this checkout.
+type store struct {
+	db *sql.DB
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Call represents a single invocation of a function, whether it ran
+// synchronously or was enqueued for async execution.
+type Call struct {
+	ID        string            `json:"id"`
+	AppID     string            `json:"app_id"`
+	Path      string            `json:"path"`
+	Type      string            `json:"type"`
+	Payload   string            `json:"payload,omitempty"`
+	Config    map[string]string `json:"config,omitempty"`
+	CreatedAt DateTime          `json:"created_at,omitempty"`
+}
+
+// CallFilter narrows a call listing query to a single app, optionally
+// starting after a given (CreatedBefore, IDBefore) keyset cursor.
+//
+// CreatedBefore/IDBefore together form one tuple predicate, not two
+// independent ones -- a datastore implementing this filter has to apply
+// them as "created_at < ? OR (created_at = ? AND id < ?)". Applying them as
+// "created_at < ? AND id < ?" silently drops every call that shares
+// CreatedBefore's timestamp but has a larger ID, which is exactly the kind
+// of skipped row offset pagination was supposed to stop happening.
+type CallFilter struct {
+	AppID         string
+	PerPage       int
+	CreatedBefore time.Time
+	IDBefore      string
+}
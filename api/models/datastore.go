@@ -0,0 +1,14 @@
+package models
+
+import "context"
+
+// Datastore is the subset of the persistence interface relevant to calls.
+// The full interface (apps, routes, ...) lives elsewhere in this tree; this
+// is just the slice needed to list calls.
+type Datastore interface {
+	// GetCalls returns calls matching filter, newest first, capped at
+	// filter.PerPage. When filter.CreatedBefore is non-zero, only calls
+	// keyset-before it are returned -- see CallFilter for how
+	// CreatedBefore/IDBefore must be applied as a single tuple predicate.
+	GetCalls(ctx context.Context, filter *CallFilter) ([]*Call, error)
+}
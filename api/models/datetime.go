@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// DateTime is time.Time with JSON (un)marshaling delegated to time.Time's
+// own, which uses RFC3339Nano, so timestamps round-trip exactly.
+type DateTime time.Time
+
+func (d DateTime) MarshalJSON() ([]byte, error) {
+	return time.Time(d).MarshalJSON()
+}
+
+func (d *DateTime) UnmarshalJSON(data []byte) error {
+	var t time.Time
+	if err := t.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	*d = DateTime(t)
+	return nil
+}
+
+func (d DateTime) String() string {
+	return time.Time(d).String()
+}
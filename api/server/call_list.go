@@ -0,0 +1,157 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fnproject/fn/api"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultCallListLimit = 30
+	maxCallListLimit     = 100
+)
+
+// callCursorKey signs pagination cursors so a client can't hand back a
+// tampered (created_at, id) predicate and walk calls it doesn't own. It's
+// process-local on purpose -- same as bufPool, it doesn't survive a restart:
+// a cursor issued before one fails verification and is rejected as an
+// invalid payload, so the caller has to start over from the first page.
+var callCursorKey = func() []byte {
+	k := make([]byte, 32)
+	if _, err := rand.Read(k); err != nil {
+		panic(err) // crypto/rand failing means the box is broken
+	}
+	return k
+}()
+
+// callCursor is the keyset predicate encoded into the opaque cursor string
+// handed back to clients.
+type callCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+func signCursor(payload []byte) []byte {
+	mac := hmac.New(sha256.New, callCursorKey)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+func encodeCallCursor(cur callCursor) string {
+	ts := cur.CreatedAt.UTC().Format(time.RFC3339Nano)
+	payload := make([]byte, 0, len(ts)+1+len(cur.ID))
+	payload = append(payload, []byte(ts)...)
+	payload = append(payload, '|')
+	payload = append(payload, []byte(cur.ID)...)
+
+	sig := signCursor(payload)
+	buf := make([]byte, 0, 2+len(payload)+len(sig))
+	var l [2]byte
+	binary.BigEndian.PutUint16(l[:], uint16(len(payload)))
+	buf = append(buf, l[:]...)
+	buf = append(buf, payload...)
+	buf = append(buf, sig...)
+
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+func decodeCallCursor(s string) (callCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(raw) < 2+sha256.Size {
+		return callCursor{}, errInvalidCursor
+	}
+
+	plen := int(binary.BigEndian.Uint16(raw[:2]))
+	if len(raw) != 2+plen+sha256.Size {
+		return callCursor{}, errInvalidCursor
+	}
+	payload := raw[2 : 2+plen]
+	sig := raw[2+plen:]
+
+	if !hmac.Equal(sig, signCursor(payload)) {
+		return callCursor{}, errInvalidCursor
+	}
+
+	parts := string(payload)
+	i := len(parts)
+	for idx := 0; idx < len(parts); idx++ {
+		if parts[idx] == '|' {
+			i = idx
+			break
+		}
+	}
+	if i == len(parts) {
+		return callCursor{}, errInvalidCursor
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[:i])
+	if err != nil {
+		return callCursor{}, errInvalidCursor
+	}
+
+	return callCursor{CreatedAt: createdAt, ID: parts[i+1:]}, nil
+}
+
+type callListResponse struct {
+	Items      []*models.Call `json:"items"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	HasNext    bool           `json:"has_next"`
+}
+
+// handleCallList serves GET /v1/apps/:app/calls?cursor=...&limit=..., listing
+// async calls keyset-paginated on (created_at, id) descending, rather than by
+// offset -- offset pages are racy under concurrent enqueues, since a newly
+// inserted call shifts every row after it and can duplicate or skip a page.
+// The cursor is an opaque, HMAC-signed token so clients can't inject
+// arbitrary predicates into the datastore query.
+func (s *Server) handleCallList(c *gin.Context) error {
+	ctx := c.Request.Context()
+	appID := c.MustGet(api.AppID).(string)
+
+	limit := defaultCallListLimit
+	if ls := c.Query("limit"); ls != "" {
+		l, err := strconv.Atoi(ls)
+		if err != nil || l <= 0 || l > maxCallListLimit {
+			return models.ErrInvalidPayload
+		}
+		limit = l
+	}
+
+	filter := &models.CallFilter{AppID: appID, PerPage: limit}
+
+	if cs := c.Query("cursor"); cs != "" {
+		cur, err := decodeCallCursor(cs)
+		if err != nil {
+			return models.ErrInvalidPayload
+		}
+		filter.CreatedBefore = cur.CreatedAt
+		filter.IDBefore = cur.ID
+	}
+
+	calls, err := s.datastore.GetCalls(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	resp := callListResponse{Items: calls}
+	if len(calls) == limit {
+		last := calls[len(calls)-1]
+		resp.NextCursor = encodeCallCursor(callCursor{CreatedAt: time.Time(last.CreatedAt), ID: last.ID})
+		resp.HasNext = true
+	}
+
+	c.JSON(http.StatusOK, resp)
+	return nil
+}
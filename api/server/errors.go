@@ -0,0 +1,107 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// HandlerError is a typed error a handler can return so WriteError has
+// enough to give the client a machine-parseable code instead of matching on
+// ad-hoc strings.
+type HandlerError struct {
+	Status  int
+	Code    string
+	Message string
+	Err     error
+}
+
+func NewHandlerError(status int, code, message string, err error) *HandlerError {
+	return &HandlerError{Status: status, Code: code, Message: message, Err: err}
+}
+
+func (e *HandlerError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *HandlerError) Unwrap() error { return e.Err }
+
+// a handful of sentinel errors from models get fixed codes so existing error
+// values keep working without every call site needing to be rewritten as a
+// *HandlerError.
+var modelErrorCodes = map[error]string{
+	models.ErrCallTimeout:           "call_timeout",
+	models.ErrCallTimeoutServerBusy: "server_too_busy",
+	models.ErrInvalidPayload:        "invalid_payload",
+}
+
+func toHandlerError(err error) *HandlerError {
+	if he, ok := err.(*HandlerError); ok {
+		return he
+	}
+	if code, ok := modelErrorCodes[err]; ok {
+		return &HandlerError{Status: http.StatusBadRequest, Code: code, Message: err.Error(), Err: err}
+	}
+	if ae, ok := err.(models.APIError); ok {
+		return &HandlerError{Status: ae.Code(), Code: "error", Message: ae.Error(), Err: ae}
+	}
+	return &HandlerError{Status: http.StatusInternalServerError, Code: "internal_error", Message: err.Error(), Err: err}
+}
+
+// WriteError writes a structured {"status","error","message","code","request"}
+// body for err, correlated with the request/call id that handleFunctionCall2
+// (or any other handler) stashed in the context logger.
+func WriteError(c *gin.Context, err error) {
+	he := toHandlerError(err)
+
+	var requestID string
+	if entry := common.Logger(c.Request.Context()); entry != nil {
+		if id, ok := entry.Data["id"].(string); ok {
+			requestID = id
+		}
+	}
+
+	c.JSON(he.Status, gin.H{
+		"status":  he.Status,
+		"error":   he.Message,
+		"message": he.Message,
+		"code":    he.Code,
+		"request": requestID,
+	})
+}
+
+// Endpoint is a handler that can fail, in the same spirit as
+// handleFunctionCall2 -- returning an error lets callers centralize error
+// formatting instead of each handler writing its own response on failure.
+type Endpoint func(c *gin.Context) error
+
+// Handle adapts an Endpoint into a gin.HandlerFunc, writing any returned
+// error via WriteError and recovering panics from inside the handler (most
+// importantly, from user function handling code) so one bad request can't
+// take the whole server down. Panics are logged with a stack trace under the
+// same request id WriteError uses.
+func Handle(endpoint Endpoint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				common.Logger(c.Request.Context()).WithFields(logrus.Fields{
+					"panic": fmt.Sprintf("%v", rec),
+					"stack": string(debug.Stack()),
+				}).Error("panic in handler")
+				WriteError(c, NewHandlerError(http.StatusInternalServerError, "internal_error", "an unexpected error occurred", nil))
+			}
+		}()
+
+		if err := endpoint(c); err != nil {
+			WriteError(c, err)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ResponsePostProcessor transforms a function's buffered output before it's
+// written to the client -- compression, format conversion, whatever an
+// operator wants to bolt on between the syncResponseWriter buffer and the
+// wire. Processors run in the order they're registered and compose: each
+// one's output becomes the next one's input. The content-type/length
+// detection block in CallFunction runs *after* the whole pipeline, so
+// headers set here (or left unset) reflect the final bytes, not the
+// function's raw ones.
+type ResponsePostProcessor interface {
+	Process(ctx context.Context, headers http.Header, body io.Reader) (io.Reader, error)
+}
+
+// postProcessorFactory builds a request-scoped ResponsePostProcessor -- the
+// built-in ones need something out of the request (Accept-Encoding, a query
+// param) to decide what, if anything, to do.
+type postProcessorFactory func(req *http.Request) ResponsePostProcessor
+
+// builtinPostProcessors holds every post-processor kind this tree actually
+// knows how to run. Notably absent: "brotli" -- this tree doesn't vendor a
+// brotli encoder (no stdlib support, and andybalholm/brotli isn't in
+// go.mod here), so rather than register a kind that silently no-ops,
+// RegisterPostProcessors below rejects it until one is wired in.
+var builtinPostProcessors = map[string]postProcessorFactory{
+	"gzip": func(req *http.Request) ResponsePostProcessor {
+		return GzipPostProcessor{AcceptEncoding: req.Header.Get("Accept-Encoding")}
+	},
+	"zip_entry": func(req *http.Request) ResponsePostProcessor {
+		return ZipEntryPostProcessor{Entry: req.URL.Query().Get("zip_entry")}
+	},
+}
+
+var (
+	postProcessorsMu sync.RWMutex
+	postProcessors   = map[string][]string{} // appID -> ordered builtinPostProcessors keys
+)
+
+// RegisterPostProcessors sets appID's post-processing pipeline to kinds, in
+// order, replacing any previously registered one. Passing no kinds clears
+// it. An unrecognized kind (including "brotli", which isn't implemented in
+// this tree) is rejected rather than silently ignored -- a misconfigured
+// pipeline should fail loudly, not quietly do nothing.
+func RegisterPostProcessors(appID string, kinds ...string) error {
+	for _, kind := range kinds {
+		if _, ok := builtinPostProcessors[kind]; !ok {
+			return fmt.Errorf("unknown post-processor kind %q", kind)
+		}
+	}
+
+	postProcessorsMu.Lock()
+	defer postProcessorsMu.Unlock()
+	if len(kinds) == 0 {
+		delete(postProcessors, appID)
+		return nil
+	}
+	postProcessors[appID] = kinds
+	return nil
+}
+
+func postProcessorsFor(appID string, req *http.Request) []ResponsePostProcessor {
+	postProcessorsMu.RLock()
+	kinds := postProcessors[appID]
+	postProcessorsMu.RUnlock()
+
+	procs := make([]ResponsePostProcessor, 0, len(kinds))
+	for _, kind := range kinds {
+		if factory, ok := builtinPostProcessors[kind]; ok {
+			procs = append(procs, factory(req))
+		}
+	}
+	return procs
+}
+
+// detectContentType guesses body's media type the same way CallFunction
+// does for unprocessed function output: http.DetectContentType doesn't
+// bother sniffing JSON, so give it a hand for that case first.
+func detectContentType(body []byte) string {
+	jsonPrefix := [1]byte{'{'} // stack allocated
+	if bytes.HasPrefix(body, jsonPrefix[:]) {
+		return "application/json; charset=utf-8"
+	}
+	return http.DetectContentType(body)
+}
+
+// runPostProcessors threads body through appID's configured pipeline, in
+// order, returning the final bytes. Each processor gets the chance to set
+// response headers (content-type, content-encoding, ...) for the bytes it
+// produces.
+func runPostProcessors(ctx context.Context, req *http.Request, appID string, headers http.Header, body []byte) ([]byte, error) {
+	procs := postProcessorsFor(appID, req)
+	if len(procs) == 0 {
+		return body, nil
+	}
+
+	var r io.Reader = bytes.NewReader(body)
+	for _, p := range procs {
+		out, err := p.Process(ctx, headers, r)
+		if err != nil {
+			return nil, err
+		}
+		r = out
+	}
+
+	return ioutil.ReadAll(r)
+}
+
+// acceptsEncoding reports whether acceptEncoding -- an Accept-Encoding
+// header value -- allows coding. It matches whole coding tokens, not
+// substrings (so "x-gzip" doesn't count as accepting "gzip"), and honors an
+// explicit "q=0" as a rejection rather than treating the token's mere
+// presence as acceptance.
+func acceptsEncoding(acceptEncoding, coding string) bool {
+	starQ := 0.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name := part
+		q := 1.0
+		if i := strings.Index(part, ";"); i >= 0 {
+			name = strings.TrimSpace(part[:i])
+			for _, p := range strings.Split(part[i+1:], ";") {
+				p = strings.TrimSpace(p)
+				if v, ok := parseQValue(p); ok {
+					q = v
+				}
+			}
+		}
+
+		if name == coding {
+			return q != 0
+		}
+		if name == "*" {
+			starQ = q
+		}
+	}
+	// no explicit entry for coding: fall back to a "*" wildcard, if any.
+	return starQ > 0
+}
+
+func parseQValue(param string) (float64, bool) {
+	if !strings.HasPrefix(param, "q=") {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// GzipPostProcessor compresses the body with gzip when the request's
+// Accept-Encoding allows it, setting Content-Encoding accordingly. It passes
+// the body through unchanged when gzip isn't acceptable, so it's safe to
+// register unconditionally and let negotiation decide per-request.
+//
+// Note the interaction with Range: serveRange refuses to honor Range against
+// a Content-Encoding'd body (a byte-offset slice of a gzip stream isn't
+// independently decodable), so once this processor sets Content-Encoding,
+// later Range requests against the same call's output get the full
+// (compressed) body rather than a partial one.
+type GzipPostProcessor struct {
+	AcceptEncoding string // value of the incoming request's Accept-Encoding header
+}
+
+func (g GzipPostProcessor) Process(ctx context.Context, headers http.Header, body io.Reader) (io.Reader, error) {
+	if !acceptsEncoding(g.AcceptEncoding, "gzip") {
+		return body, nil
+	}
+
+	raw, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Content-Type has to describe the decoded representation, not
+	// "application/x-gzip" -- detect it off the pre-compression bytes now,
+	// since whatever runs content-type detection after the pipeline only
+	// sees the (by then compressed) output.
+	if headers.Get("Content-Type") == "" {
+		headers.Set("Content-Type", detectContentType(raw))
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(raw); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	headers.Set("Content-Encoding", "gzip")
+	return &buf, nil
+}
+
+// ZipEntryPostProcessor serves a single named entry out of a zip file
+// returned by the function, via ?zip_entry=path/in/archive. It reads the
+// central directory at the tail of the already-buffered body, so it needs
+// the whole thing in memory -- which it already is, by the time the
+// pipeline runs.
+type ZipEntryPostProcessor struct {
+	Entry string // value of the ?zip_entry query param; empty means passthrough
+}
+
+func (z ZipEntryPostProcessor) Process(ctx context.Context, headers http.Header, body io.Reader) (io.Reader, error) {
+	if z.Entry == "" {
+		return body, nil
+	}
+
+	raw, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		return nil, NewHandlerError(http.StatusBadRequest, "invalid_zip_body", "function output is not a valid zip archive", err)
+	}
+
+	for _, f := range zr.File {
+		if f.Name != z.Entry {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+
+		data, err := ioutil.ReadAll(rc)
+		if err != nil {
+			return nil, err
+		}
+
+		// the function's own Content-Type describes the archive, not the
+		// entry, so always replace it.
+		ct := mime.TypeByExtension(filepath.Ext(f.Name))
+		if ct == "" {
+			ct = detectContentType(data)
+		}
+		headers.Set("Content-Type", ct)
+		return bytes.NewReader(data), nil
+	}
+
+	return nil, NewHandlerError(http.StatusNotFound, "zip_entry_not_found", fmt.Sprintf("no entry %q in zip archive", z.Entry), nil)
+}
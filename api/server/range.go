@@ -0,0 +1,201 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// httpRange is a single byte range parsed out of a Range header, resolved
+// against a known total length.
+type httpRange struct {
+	start, length int64
+}
+
+func (r httpRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.start+r.length-1, size)
+}
+
+// errMalformedRange means the header didn't parse as valid Range syntax at
+// all, as opposed to parsing fine but none of its ranges being satisfiable
+// against size -- per RFC 7233 §3.1, the former means the header must be
+// ignored (serve the full body), the latter means 416.
+var errMalformedRange = errors.New("malformed range")
+
+// parseRange parses a Range header of the form "bytes=a-b,c-d" against size,
+// the same grammar net/http.ServeContent understands. Range-specs that are
+// individually unsatisfiable (e.g. a start past the end of the body) are
+// dropped rather than failing the whole header -- RFC 7233 only calls for a
+// 416 when *none* of the requested ranges can be satisfied, so a request
+// mixing a valid and an out-of-bounds range should still get the valid one
+// back. parseRange returns errMalformedRange if the header doesn't parse as
+// Range syntax at all, or an empty slice (no error) if it parses fine but
+// every range in it is out of bounds.
+func parseRange(s string, size int64) ([]httpRange, error) {
+	const b = "bytes="
+	if !strings.HasPrefix(s, b) {
+		return nil, errMalformedRange
+	}
+
+	var ranges []httpRange
+	for _, ra := range strings.Split(s[len(b):], ",") {
+		ra = strings.TrimSpace(ra)
+		if ra == "" {
+			continue
+		}
+		i := strings.Index(ra, "-")
+		if i < 0 {
+			return nil, errMalformedRange
+		}
+		startStr, endStr := strings.TrimSpace(ra[:i]), strings.TrimSpace(ra[i+1:])
+
+		var r httpRange
+		if startStr == "" {
+			// suffix range: "-N" means the last N bytes
+			n, err := strconv.ParseInt(endStr, 10, 64)
+			if err != nil || n < 0 {
+				return nil, errMalformedRange
+			}
+			if n > size {
+				n = size
+			}
+			if n == 0 {
+				// "-0", or any suffix against an empty body: syntactically
+				// fine, just unsatisfiable -- drop this range.
+				continue
+			}
+			r.start = size - n
+			r.length = n
+		} else {
+			start, err := strconv.ParseInt(startStr, 10, 64)
+			if err != nil || start < 0 {
+				return nil, errMalformedRange
+			}
+			if start >= size {
+				// syntactically fine, just out of bounds -- drop this range,
+				// don't fail the whole header.
+				continue
+			}
+			end := size - 1
+			if endStr != "" {
+				e, err := strconv.ParseInt(endStr, 10, 64)
+				if err != nil || e < start {
+					return nil, errMalformedRange
+				}
+				if e < end {
+					end = e
+				}
+			}
+			r.start = start
+			r.length = end - start + 1
+		}
+		ranges = append(ranges, r)
+	}
+
+	return ranges, nil
+}
+
+// etagFor returns a strong ETag derived from the sha256 of buf, letting
+// clients safely resume a download with If-Range without us keeping any
+// extra per-call state around.
+func etagFor(buf []byte) string {
+	sum := sha256.Sum256(buf)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// serveRange commits w's status and Range/If-Range-related headers for buf
+// against r, and returns the body still left to write -- the full buf for a
+// plain request, a single range's slice with a 206 Content-Range, or a
+// multipart/byteranges body for several ranges. It commits a bodyless 416 if
+// the Range header parses but none of its ranges are satisfiable, same as
+// the non-2xx branch in CallFunction, so the returned reader is nil only
+// when there's genuinely nothing left to write. This mirrors CallFunction's
+// contract of committing headers/status itself but leaving the body write
+// to its caller.
+func serveRange(w http.ResponseWriter, r *http.Request, header http.Header, buf []byte) io.Reader {
+	size := int64(len(buf))
+	etag := etagFor(buf)
+	header.Set("ETag", etag)
+
+	writeFull := func() io.Reader {
+		header.Set("Content-Length", strconv.FormatInt(size, 10))
+		w.WriteHeader(http.StatusOK)
+		return bytes.NewReader(buf)
+	}
+
+	rangeHeader := r.Header.Get("Range")
+
+	// a body that's already been content-encoded (gzip, ...) can't be
+	// partially decoded by the client from a byte-offset slice of the
+	// compressed stream, so don't advertise or honor Range against it --
+	// just serve the whole encoded body with a 200. The one difference from a
+	// request without Range is a "Content-Range: bytes */N" header giving the
+	// encoded body's size.
+	if header.Get("Content-Encoding") != "" {
+		if rangeHeader != "" {
+			header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		}
+		return writeFull()
+	}
+
+	header.Set("Accept-Ranges", "bytes")
+
+	if rangeHeader == "" {
+		return writeFull()
+	}
+
+	if ifRange := r.Header.Get("If-Range"); ifRange != "" && ifRange != etag {
+		// the resource changed since the client's last fetch -- send the
+		// whole thing again rather than a stale range.
+		return writeFull()
+	}
+
+	ranges, err := parseRange(rangeHeader, size)
+	if err == errMalformedRange {
+		// the header isn't valid Range syntax at all -- ignore it and serve
+		// the full body, same as if it had been absent.
+		return writeFull()
+	}
+	if len(ranges) == 0 {
+		// parsed fine, but every range in it was out of bounds.
+		header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		return nil
+	}
+
+	if len(ranges) == 1 {
+		ra := ranges[0]
+		header.Set("Content-Range", ra.contentRange(size))
+		header.Set("Content-Length", strconv.FormatInt(ra.length, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		return bytes.NewReader(buf[ra.start : ra.start+ra.length])
+	}
+
+	// multi-range: build a multipart/byteranges body.
+	contentType := header.Get("Content-Type")
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, ra := range ranges {
+		partHeader := make(map[string][]string)
+		if contentType != "" {
+			partHeader["Content-Type"] = []string{contentType}
+		}
+		partHeader["Content-Range"] = []string{ra.contentRange(size)}
+		pw, _ := mw.CreatePart(partHeader)
+		pw.Write(buf[ra.start : ra.start+ra.length])
+	}
+	mw.Close()
+
+	header.Set("Content-Type", mime.FormatMediaType("multipart/byteranges", map[string]string{"boundary": mw.Boundary()}))
+	header.Set("Content-Length", strconv.Itoa(body.Len()))
+	w.WriteHeader(http.StatusPartialContent)
+	return &body
+}
@@ -0,0 +1,13 @@
+package server
+
+import "github.com/gin-gonic/gin"
+
+// addCallListRoute wires GET /v1/apps/:app/calls to handleCallList onto v1.
+// The rest of the route table (apps, routes, the function-call catch-all,
+// ...) is built in this package's main router setup, which isn't part of
+// this checkout -- this just adds the one route this change introduces.
+// Call it alongside the other v1.GET/.../POST registrations when building
+// the router.
+func (s *Server) addCallListRoute(v1 *gin.RouterGroup) {
+	v1.GET("/apps/:app/calls", Handle(s.handleCallList))
+}
@@ -19,10 +19,7 @@ import (
 
 // handleFunctionCall executes the function, for router handlers
 func (s *Server) handleFunctionCall(c *gin.Context) {
-	err := s.handleFunctionCall2(c)
-	if err != nil {
-		handleErrorResponse(c, err)
-	}
+	Handle(s.handleFunctionCall2)(c)
 }
 
 // handleFunctionCall2 executes the function and returns an error
@@ -62,36 +59,69 @@ type CallResponse struct {
 }
 
 // CallFunction exposed to become the API extension, to let API listeners to be capable to call a functions
-// defined by app and path
+// defined by app and path.
+//
+// Contract for the sync case: CallFunction itself commits respWriter's
+// status and headers (including Content-Type/Content-Length/Range-related
+// headers), but leaves writing the body to the caller via the returned
+// io.Reader, same as the baseline behavior this was built on -- it does
+// *not* write the body itself. That holds for both the non-2xx branch and
+// the normal pipeline+Range branch below, so callers always get a non-nil
+// reader back when there's a body to write. The exceptions, where the
+// returned reader is nil because there genuinely is nothing left to write,
+// are the async case (body already consumed into the enqueued call), the
+// streaming case (the function's output was already flushed straight to
+// respWriter as it was produced) and a Range request none of whose ranges
+// is satisfiable (serveRange has already committed a bodyless 416).
 func (s *Server) CallFunction(app *models.App, path string, req *http.Request,
 	respWriter http.ResponseWriter) (*string, io.Reader, error) {
 
 	buf := bufPool.Get().(*bytes.Buffer)
 	buf.Reset()
-	writer := syncResponseWriter{
-		Buffer:  buf,
-		headers: respWriter.Header(), // copy ref
-	}
 	defer bufPool.Put(buf) // TODO need to ensure this is safe with Dispatch?
 
-	// GetCall can mod headers, assign an id, look up the route/app (cached),
-	// strip params, etc.
-	// this should happen ASAP to turn app name to app ID
+	// streaming is opt-in per route, via app.Config -- the same map that
+	// already carries per-route settings like timeouts and memory, just
+	// namespaced by path since there's no per-route model to hang a
+	// "streaming: true" field off in this tree. It has to be known before
+	// GetCall so we hand the agent the writer we're actually going to use.
+	streaming := isRouteStreaming(app, path)
+
+	var writer syncResponseWriter
+	var writerOpt agent.CallOpt
+	var streamWriter *streamingResponseWriter
+
+	if streaming {
+		flusher, _ := respWriter.(http.Flusher)
+		streamWriter = &streamingResponseWriter{w: respWriter, flusher: flusher}
+		writerOpt = agent.WithStreamingWriter(streamWriter)
+	} else {
+		writer = syncResponseWriter{
+			Buffer:  buf,
+			headers: respWriter.Header(), // copy ref
+		}
+		writerOpt = agent.WithWriter(&writer)
+	}
 
 	// GetCall can mod headers, assign an id, look up the route/app (cached),
 	// strip params, etc.
+	// this should happen ASAP to turn app name to app ID
 
 	call, err := s.agent.GetCall(
-		agent.WithWriter(&writer), // XXX (reed): order matters [for now]
+		writerOpt, // XXX (reed): order matters [for now]
 		agent.FromRequest(app, path, req),
 	)
 	if err != nil {
 		return nil, nil, err
 	}
 	model := call.Model()
-	{ // scope this, to disallow ctx use outside of this scope. add id for handleErrorResponse logger
+	{ // scope this, to disallow ctx use outside of this scope. add id for WriteError's logger
+		// req is the same *http.Request the caller's gin.Context holds (serve
+		// passes c.Request straight through); mutate it in place via the
+		// pointer rather than reassigning the local var, or the id never makes
+		// it back to c.Request.Context() and WriteError can't see it.
 		ctx, _ := common.LoggerWithFields(req.Context(), logrus.Fields{"id": model.ID})
-		req = req.WithContext(ctx)
+		*req = *req.WithContext(ctx)
 	}
 
 	if model.Type == "async" {
@@ -122,30 +152,60 @@ func (s *Server) CallFunction(app *models.App, path string, req *http.Request,
 			// add this, since it means that start may not have been called [and it's relevant]
 			respWriter.Header().Add("XXX-FXLB-WAIT", time.Now().Sub(time.Time(model.CreatedAt)).String())
 		}
+		if streamWriter != nil && streamWriter.wroteHeader {
+			// the function already flushed (part of) its response straight to
+			// the client before Submit returned this error -- headers are
+			// committed and possibly a body too, so there's no clean way left
+			// to report this as an HTTP error without corrupting what's
+			// already on the wire. Log it and stop; WriteError would just
+			// issue a superfluous WriteHeader and tack a JSON error body onto
+			// the stream.
+			common.Logger(req.Context()).WithError(err).Error("error after streaming response had already started")
+			return nil, nil, nil
+		}
 		return nil, nil, err
 	}
 
-	// if they don't set a content-type - detect it
-	if writer.Header().Get("Content-Type") == "" {
-		// see http.DetectContentType, the go server is supposed to do this for us but doesn't appear to?
-		var contentType string
-		jsonPrefix := [1]byte{'{'} // stack allocated
-		if bytes.HasPrefix(buf.Bytes(), jsonPrefix[:]) {
-			// try to detect json, since DetectContentType isn't a hipster.
-			contentType = "application/json; charset=utf-8"
-		} else {
-			contentType = http.DetectContentType(buf.Bytes())
+	if streaming {
+		// streamWriter already flushed directly to respWriter as the function
+		// wrote its output; there is nothing left to buffer or detect headers
+		// from.
+		return nil, nil, nil
+	}
+
+	// the post-processing pipeline (compression, zip_entry extraction, ...)
+	// and Range/If-Range only make sense against a normal, successful body.
+	// Gate on the status *class*, not on an exact match against 200 -- a
+	// function that set 201, 204 or 206 itself is still a successful
+	// response and should still get compression/Range handling; only a
+	// non-2xx status means "skip straight to serving the raw buffer and let
+	// the client deal with it."
+	if writer.status != 0 && writer.status/100 != 2 {
+		if writer.Header().Get("Content-Type") == "" {
+			writer.Header().Set("Content-Type", detectContentType(buf.Bytes()))
 		}
-		writer.Header().Set("Content-Type", contentType)
+		writer.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+		respWriter.WriteHeader(writer.status)
+		return nil, writer, nil
 	}
 
-	writer.Header().Set("Content-Length", strconv.Itoa(int(buf.Len())))
+	body, err := runPostProcessors(req.Context(), req, app.ID, writer.Header(), buf.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
 
-	if writer.status > 0 {
-		respWriter.WriteHeader(writer.status)
+	// if they don't set a content-type - detect it. Processors that change
+	// the body's media type (gzip, zip_entry, ...) already set this for the
+	// decoded bytes themselves, so this is just the passthrough fallback.
+	if writer.Header().Get("Content-Type") == "" {
+		writer.Header().Set("Content-Type", detectContentType(body))
 	}
 
-	return nil, writer, nil
+	// serveRange commits status/headers and hands back the body to write,
+	// same as the non-2xx branch above -- it does not write the body itself,
+	// so this path returns a non-nil reader whenever there is a body (it is
+	// nil only after an unsatisfiable-range 416).
+	return nil, serveRange(respWriter, req, writer.Header(), body), nil
 }
 
 // TODO it would be nice if we could make this have nothing to do with the gin.Context but meh
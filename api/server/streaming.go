@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/fnproject/fn/api/models"
+)
+
+// isRouteStreaming reports whether path within app opted into streaming
+// responses.
+//
+// NEEDS SIGN-OFF: the original ask was a "streaming: true" field on the
+// route/function model itself. There's no per-route model in this tree to
+// hang that field off of, so this reads it out of the app's Config instead,
+// namespaced by path so each route can still be toggled independently of
+// the others in the same app. That's a real deviation from the requested
+// data model, not just an implementation detail -- a stringly-typed,
+// path-namespaced Config key is easy to typo and isn't discoverable the way
+// a real field would be. Landing this as-is should be a deliberate call by
+// whoever owns the route model, not something that slides through because
+// it happens to work; if/when a real per-route model exists here, this
+// should move onto it instead.
+func isRouteStreaming(app *models.App, path string) bool {
+	return app.Config["FN_STREAM:"+path] == "true"
+}
+
+var _ http.ResponseWriter = new(streamingResponseWriter)
+
+// streamingResponseWriter is a sibling of syncResponseWriter for routes that
+// opt into streaming: instead of buffering the whole function response and
+// flushing it once Submit returns, it writes straight through to the
+// underlying http.ResponseWriter and flushes after every write, so a
+// function can emit server-sent events, long-poll updates or progressive
+// JSON without waiting to finish. Content-Type/Content-Length detection and
+// the buffered error swap that syncResponseWriter gets for free do not apply
+// here -- the first byte written commits the headers.
+type streamingResponseWriter struct {
+	w           http.ResponseWriter
+	flusher     http.Flusher // nil if the underlying writer doesn't support it
+	status      int
+	wroteHeader bool
+}
+
+func (s *streamingResponseWriter) Header() http.Header { return s.w.Header() }
+
+func (s *streamingResponseWriter) WriteHeader(code int) {
+	if s.wroteHeader {
+		return
+	}
+	s.status = code
+	s.wroteHeader = true
+	s.w.WriteHeader(code)
+}
+
+func (s *streamingResponseWriter) Write(p []byte) (int, error) {
+	if !s.wroteHeader {
+		// no Content-Length is known up front, so let the server fall back to
+		// chunked transfer encoding rather than guessing a length.
+		s.WriteHeader(http.StatusOK)
+	}
+	n, err := s.w.Write(p)
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+	return n, err
+}